@@ -1,25 +1,47 @@
 package main
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"debug/pe"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/foobaz/go-zopfli/zopfli"
 	"github.com/kjk/u"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"golang.org/x/crypto/scrypt"
 )
 
 var (
 	pdbFiles = []string{"libmupdf.pdb", "SumatraPDF-dll.pdb", "SumatraPDF.pdb"}
 )
 
+// signifyKeyEnvVar names the env var holding the signify/minisign secret key
+// used to sign release artifacts (its public counterpart lives in
+// signifyKeyEnvVar+"_PUB", its passphrase in signifyKeyEnvVar+"_PASSPHRASE").
+const signifyKeyEnvVar = "SIGNIFY_SECRET_KEY"
+
 var (
 	preReleaseVerCached string
 	gitSha1Cached       string
@@ -50,6 +72,10 @@ func verifyCorrectVersionMust(ver string) {
 	}
 }
 
+// getFileNamesWithPrefix maps built file names to their renamed, published
+// form. copyBuiltFiles additionally writes a "<dst>.sha256" checksum sidecar
+// next to each copied file, so callers don't need a separate entry here for
+// the .sha256 suffix.
 func getFileNamesWithPrefix(prefix string) [][]string {
 	files := [][]string{
 		{"SumatraPDF.exe", fmt.Sprintf("%s.exe", prefix)},
@@ -71,17 +97,26 @@ func copyBuiltFiles(dstDir string, srcDir string, prefix string) {
 		must(createDirForFile(dstPath))
 		if fileExists(srcPath) {
 			must(copyFile(dstPath, srcPath))
+			writeFileMust(dstPath+".sha256", []byte(fmt.Sprintf("%s  %s", sha256HexOfFileMust(dstPath), dstName)))
 		} else {
 			logf("Skipping copying '%s'\n", srcPath)
 		}
 	}
 }
 
+// copyBuiltManifest copies out/artifacts/manifest.txt and manifest.sha256
+// into dstDir, renamed with the same <prefix>-manifest.<ext> scheme.
 func copyBuiltManifest(dstDir string, prefix string) {
-	srcPath := filepath.Join("out", "artifacts", "manifest.txt")
-	dstName := prefix + "-manifest.txt"
-	dstPath := filepath.Join(dstDir, dstName)
-	must(copyFile(dstPath, srcPath))
+	artifactsDir := filepath.Join("out", "artifacts")
+	for _, name := range []string{"manifest.txt", "manifest.sha256"} {
+		srcPath := filepath.Join(artifactsDir, name)
+		if !fileExists(srcPath) {
+			continue
+		}
+		dstName := prefix + "-" + name
+		dstPath := filepath.Join(dstDir, dstName)
+		must(copyFile(dstPath, srcPath))
+	}
 }
 
 func extractSumatraVersionMust() string {
@@ -250,48 +285,133 @@ func addZipFileMust(w *zip.Writer, path string) {
 }
 
 func createExeZipWithGoWithNameMust(dir, nameInZip string) {
+	path := filepath.Join(dir, "SumatraPDF.exe")
+
+	if buildCompressKind != "" && buildCompressKind != "zip" {
+		c := getCompressorMust(buildCompressKind)
+		dstPath := filepath.Join(dir, "SumatraPDF."+extForCompressor(c))
+		os.Remove(dstPath)
+		c.CompressFileMust(dstPath, path)
+		return
+	}
+
 	zipPath := filepath.Join(dir, "SumatraPDF.zip")
 	os.Remove(zipPath) // called multiple times during upload
 	f, err := os.Create(zipPath)
 	must(err)
 	defer f.Close()
 	zw := zip.NewWriter(f)
-	path := filepath.Join(dir, "SumatraPDF.exe")
 	addZipFileWithNameMust(zw, path, nameInZip)
 	err = zw.Close()
 	must(err)
 }
 
-// func createExeZipWithPigz(dir string) {
-// 	srcFile := "SumatraPDF.exe"
-// 	srcPath := filepath.Join(dir, srcFile)
-// 	panicIf(!fileExists(srcPath), "file '%s' doesn't exist\n", srcPath)
-
-// 	// this is the file that pigz.exe will create
-// 	dstFileTmp := "SumatraPDF.exe.zip"
-// 	dstPathTmp := filepath.Join(dir, dstFileTmp)
-// 	removeFileMust(dstPathTmp)
-
-// 	// this is the file we want at the end
-// 	dstFile := "SumatraPDF.zip"
-// 	dstPath := filepath.Join(dir, dstFile)
-// 	removeFileMust(dstPath)
-
-// 	wd, err := os.Getwd()
-// 	must(err)
-// 	pigzExePath := filepath.Join(wd, "bin", "pigz.exe")
-// 	panicIf(!fileExists(pigzExePath), "file '%s' doesn't exist\n", pigzExePath)
-// 	cmd := exec.Command(pigzExePath, "-11", "--keep", "--zip", srcFile)
-// 	// in pigz we don't control the name of the file created inside so
-// 	// so when we run pigz the current directory is the same as
-// 	// the directory with the file we're compressing
-// 	cmd.Dir = dir
-// 	runCmdMust(cmd)
-
-// 	panicIf(!fileExists(dstPathTmp), "file '%s' doesn't exist\n", dstPathTmp)
-// 	err = os.Rename(dstPathTmp, dstPath)
-// 	must(err)
-// }
+// flgZopfli is the --zopfli flag. zopfli produces noticeably smaller zips
+// than flate but is 50-100x slower, so buildRelease is the only build entry
+// point that consults it; buildCiDaily never does.
+var flgZopfli = flag.Bool("zopfli", false, "use slow, high-ratio zopfli compression for release zips (buildRelease only)")
+
+// useZopfli mirrors *flgZopfli once applyZopfliFlag() has run.
+var useZopfli = false
+
+// applyZopfliFlag reads flgZopfli into useZopfli. Called once at the start
+// of buildRelease.
+func applyZopfliFlag() {
+	useZopfli = *flgZopfli
+}
+
+var registerZopfliOnce sync.Once
+
+// enableZopfliCompressionMust registers a pure-Go zopfli-backed compressor
+// for zip.Deflate, replacing the standard library's flate implementation for
+// every zip.Writer created afterwards.
+func enableZopfliCompressionMust() {
+	useZopfli = true
+	registerZopfliOnce.Do(func() {
+		zip.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return &zopfliWriteCloser{w: w}, nil
+		})
+	})
+}
+
+// zopfliWriteCloser buffers everything written to it and runs zopfli's
+// deflate compressor over the whole buffer on Close, since zopfli (unlike
+// flate) isn't a streaming compressor.
+type zopfliWriteCloser struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (z *zopfliWriteCloser) Write(p []byte) (int, error) {
+	return z.buf.Write(p)
+}
+
+func (z *zopfliWriteCloser) Close() error {
+	opts := zopfli.DefaultOptions()
+	return zopfli.DeflateCompress(opts, z.buf.Bytes(), z.w)
+}
+
+// createExeZipsWithZopfliParallelMust re-creates SumatraPDF.zip for each of
+// dirs using the zopfli-registered compressor, spread across a worker pool
+// sized to runtime.NumCPU() since zopfli is slow enough that doing all three
+// platforms serially would noticeably lengthen buildRelease.
+func createExeZipsWithZopfliParallelMust(dirs []string, ver string) {
+	enableZopfliCompressionMust()
+
+	type job struct {
+		dir       string
+		nameInZip string
+	}
+	var jobs []job
+	suffixes := map[string]string{rel32Dir: "32", rel64Dir: "64", relArm64Dir: "arm64"}
+	for _, dir := range dirs {
+		jobs = append(jobs, job{dir: dir, nameInZip: fmt.Sprintf("SumatraPDF-%s-%s.exe", ver, suffixes[dir])})
+	}
+
+	nWorkers := runtime.NumCPU()
+	if nWorkers > len(jobs) {
+		nWorkers = len(jobs)
+	}
+	jobCh := make(chan job, len(jobs))
+	for _, j := range jobs {
+		jobCh <- j
+	}
+	close(jobCh)
+
+	var wg sync.WaitGroup
+	for i := 0; i < nWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobCh {
+				printDur := makePrintDuration(fmt.Sprintf("zopfli-compressing '%s'", j.nameInZip))
+				plainSize := deflateSizeMust(filepath.Join(j.dir, "SumatraPDF.exe"))
+				createExeZipWithGoWithNameMust(j.dir, j.nameInZip)
+				printDur()
+				zopfliSize := fileSizeMust(filepath.Join(j.dir, "SumatraPDF.zip"))
+				logf("zopfli: '%s': plain deflate %d, zopfli %d (%.1f%% smaller)\n",
+					j.nameInZip, plainSize, zopfliSize, 100*(1-float64(zopfliSize)/float64(plainSize)))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// deflateSizeMust returns the size of path compressed with the standard
+// library's flate.BestCompression (deliberately bypassing zip.Writer, whose
+// registered Deflate compressor may by now be zopfli), used only to log
+// zopfli's win in createExeZipsWithZopfliParallelMust.
+func deflateSizeMust(path string) int64 {
+	d, err := os.ReadFile(path)
+	must(err)
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.BestCompression)
+	must(err)
+	_, err = fw.Write(d)
+	must(err)
+	must(fw.Close())
+	return int64(buf.Len())
+}
 
 func createPdbZipMust(dir string) {
 	path := filepath.Join(dir, "SumatraPDF.pdb.zip")
@@ -319,9 +439,250 @@ func createPdbLzsaMust(dir string) {
 	runCmdLoggedMust(cmd)
 }
 
+// Compressor is the archive/compression backend selected by the
+// --compress=zip|zstd|xz|lzsa flag (see flgCompress). zip remains the
+// default; zstd and xz trade extra CPU for noticeably smaller downloads;
+// lzsa shells out to the same MakeLZSA.exe the installer's embedded pdb
+// archive always uses regardless of --compress, and can't be decompressed
+// from Go (see lzsaCompressorT).
+type Compressor interface {
+	Name() string
+	// CompressFileMust compresses srcPath into dstPath as a single stream.
+	CompressFileMust(dstPath, srcPath string)
+	// DecompressFileMust reverses CompressFileMust.
+	DecompressFileMust(dstPath, srcPath string)
+}
+
+// flgCompress is the --compress=zip|zstd|xz|lzsa flag. It affects
+// createExeZipWithGoWithNameMust, the parallel pdb archive and the files
+// buildCiDaily uploads; the LZSA archive consumed by the installer is
+// unaffected. Read via
+// applyCompressFlagMust() at the start of buildRelease / buildPreRelease /
+// buildCiDaily.
+var flgCompress = flag.String("compress", "zip", "compression backend for release archives: zip, zstd, xz, lzsa")
+
+// buildCompressKind holds the effective compressor kind once
+// applyCompressFlagMust() has validated flgCompress.
+var buildCompressKind = "zip"
+
+// applyCompressFlagMust validates flgCompress and stores it in
+// buildCompressKind. Called once at the start of every build entry point
+// that packages release artifacts.
+func applyCompressFlagMust() {
+	setBuildCompressKindMust(*flgCompress)
+}
+
+func setBuildCompressKindMust(kind string) {
+	switch kind {
+	case "zip", "zstd", "xz", "lzsa", "":
+		buildCompressKind = kind
+	default:
+		panic(fmt.Sprintf("unrecognized --compress value '%s'", kind))
+	}
+}
+
+type zipCompressorT struct{}
+
+func (zipCompressorT) Name() string { return "zip" }
+
+func (zipCompressorT) CompressFileMust(dstPath, srcPath string) {
+	os.Remove(dstPath)
+	f, err := os.Create(dstPath)
+	must(err)
+	defer f.Close()
+	w := zip.NewWriter(f)
+	addZipFileMust(w, srcPath)
+	must(w.Close())
+}
+
+func (zipCompressorT) DecompressFileMust(dstPath, srcPath string) {
+	zr, err := zip.OpenReader(srcPath)
+	must(err)
+	defer zr.Close()
+	panicIf(len(zr.File) != 1, "expected a single-file zip archive in '%s'", srcPath)
+	rc, err := zr.File[0].Open()
+	must(err)
+	defer rc.Close()
+	d, err := io.ReadAll(rc)
+	must(err)
+	writeFileMust(dstPath, d)
+}
+
+// zstdCompressorT uses github.com/klauspost/compress/zstd at
+// SpeedBestCompression, that library's highest level (roughly zstd level
+// 11); it offers no level matching zstd's 19-22.
+type zstdCompressorT struct{}
+
+func (zstdCompressorT) Name() string { return "zstd" }
+
+func (zstdCompressorT) CompressFileMust(dstPath, srcPath string) {
+	d, err := os.ReadFile(srcPath)
+	must(err)
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	must(err)
+	defer enc.Close()
+	writeFileMust(dstPath, enc.EncodeAll(d, nil))
+}
+
+func (zstdCompressorT) DecompressFileMust(dstPath, srcPath string) {
+	d, err := os.ReadFile(srcPath)
+	must(err)
+	dec, err := zstd.NewReader(nil)
+	must(err)
+	defer dec.Close()
+	out, err := dec.DecodeAll(d, nil)
+	must(err)
+	writeFileMust(dstPath, out)
+}
+
+// xzCompressorT uses github.com/ulikunitz/xz with the LZMA2 preset 6.
+type xzCompressorT struct{}
+
+func (xzCompressorT) Name() string { return "xz" }
+
+func (xzCompressorT) CompressFileMust(dstPath, srcPath string) {
+	d, err := os.ReadFile(srcPath)
+	must(err)
+	f, err := os.Create(dstPath)
+	must(err)
+	defer f.Close()
+	cfg := xz.WriterConfig{Preset: 6}
+	must(cfg.Verify())
+	w, err := cfg.NewWriter(f)
+	must(err)
+	_, err = w.Write(d)
+	must(err)
+	must(w.Close())
+}
+
+func (xzCompressorT) DecompressFileMust(dstPath, srcPath string) {
+	f, err := os.Open(srcPath)
+	must(err)
+	defer f.Close()
+	r, err := xz.NewReader(f)
+	must(err)
+	d, err := io.ReadAll(r)
+	must(err)
+	writeFileMust(dstPath, d)
+}
+
+// lzsaCompressorT shells out to bin/MakeLZSA.exe, the same tool
+// createPdbLzsaMust uses to build the installer's embedded pdb archive.
+// MakeLZSA only packs archives, it has no extraction mode anywhere in this
+// repo (the app itself unpacks .lzsa via its own C++ reader), so
+// DecompressFileMust panics rather than pretending round-tripping works.
+type lzsaCompressorT struct{}
+
+func (lzsaCompressorT) Name() string { return "lzsa" }
+
+func (lzsaCompressorT) CompressFileMust(dstPath, srcPath string) {
+	lzsa := absPathMust(filepath.Join("bin", "MakeLZSA.exe"))
+	panicIf(!fileExists(lzsa), "file '%s' doesn't exist", lzsa)
+	srcDir := filepath.Dir(srcPath)
+	srcName := filepath.Base(srcPath)
+	tmpName := srcName + ".lzsa.tmp"
+	cmd := exec.Command(lzsa, tmpName, srcName+":"+srcName)
+	cmd.Dir = srcDir
+	runCmdLoggedMust(cmd)
+	must(copyFile(dstPath, filepath.Join(srcDir, tmpName)))
+	os.Remove(filepath.Join(srcDir, tmpName))
+}
+
+func (lzsaCompressorT) DecompressFileMust(dstPath, srcPath string) {
+	panic("lzsaCompressorT.DecompressFileMust: MakeLZSA has no extraction mode, .lzsa archives can't be decompressed from here")
+}
+
+func getCompressorMust(kind string) Compressor {
+	switch kind {
+	case "", "zip":
+		return zipCompressorT{}
+	case "zstd":
+		return zstdCompressorT{}
+	case "xz":
+		return xzCompressorT{}
+	case "lzsa":
+		return lzsaCompressorT{}
+	}
+	panic(fmt.Sprintf("unrecognized compressor kind '%s'", kind))
+}
+
+func extForCompressor(c Compressor) string {
+	switch c.Name() {
+	case "zstd":
+		return "zst"
+	case "xz":
+		return "xz"
+	case "lzsa":
+		return "lzsa"
+	default:
+		return "zip"
+	}
+}
+
+// detectCompressorFromMagicMust sniffs the first bytes of path to figure out
+// which Compressor produced it (zstd: 28 B5 2F FD, xz: FD 37 7A 58 5A,
+// zip: "PK"), so the verify path can decompress regardless of what
+// --compress was used when the artifact was built.
+func detectCompressorFromMagicMust(path string) Compressor {
+	f, err := os.Open(path)
+	must(err)
+	defer f.Close()
+	var magic [6]byte
+	n, _ := io.ReadFull(f, magic[:])
+	b := magic[:n]
+	switch {
+	case bytes.HasPrefix(b, []byte{0x28, 0xB5, 0x2F, 0xFD}):
+		return zstdCompressorT{}
+	case bytes.HasPrefix(b, []byte{0xFD, 0x37, 0x7A, 0x58, 0x5A}):
+		return xzCompressorT{}
+	case bytes.HasPrefix(b, []byte("PK")):
+		return zipCompressorT{}
+	}
+	panic(fmt.Sprintf("can't detect compressor for '%s' from magic bytes", path))
+}
+
+// createPdbCompressedMust bundles pdbFiles from dir into a tar stream and
+// compresses it with c, producing e.g. SumatraPDF.pdb.zst. It's produced
+// alongside createPdbZipMust / createPdbLzsaMust when --compress selects zstd
+// or xz, and stays in dir: getFileNamesWithPrefix has no entry for it, so
+// copyBuiltFiles doesn't copy it to the final dir. The installer always
+// embeds the LZSA archive. No-op when c is the zip
+// compressor, since createPdbZipMust already covers that case.
+func createPdbCompressedMust(dir string, c Compressor) {
+	if c.Name() == "zip" || c.Name() == "lzsa" {
+		// zip is covered by createPdbZipMust; lzsa is covered by
+		// createPdbLzsaMust, which already bundles pdbFiles directly
+		// without going through this tar-then-compress path.
+		return
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, file := range pdbFiles {
+		path := filepath.Join(dir, file)
+		fi, err := os.Stat(path)
+		must(err)
+		hdr, err := tar.FileInfoHeader(fi, "")
+		must(err)
+		must(tw.WriteHeader(hdr))
+		d, err := os.ReadFile(path)
+		must(err)
+		_, err = tw.Write(d)
+		must(err)
+	}
+	must(tw.Close())
+
+	tarPath := filepath.Join(dir, "SumatraPDF.pdb.tar")
+	writeFileMust(tarPath, buf.Bytes())
+	defer os.Remove(tarPath)
+
+	dstPath := filepath.Join(dir, "SumatraPDF.pdb."+extForCompressor(c))
+	c.CompressFileMust(dstPath, tarPath)
+}
+
 // manifest is build for pre-release builds and contains information about file sizes
 func createManifestMust() {
 	var lines []string
+	var sha256Lines []string
 	files := []string{
 		"SumatraPDF.exe",
 		"SumatraPDF.zip",
@@ -340,12 +701,22 @@ func createManifestMust() {
 		}
 	}
 	panicIf(len(dirs) == 0, "didn't find any dirs for the manifest")
+	sizes := map[string]map[string]*PESectionSizes{}
 	for _, dir := range dirs {
+		platform := platformForDir(dir)
 		for _, file := range files {
 			path := filepath.Join(dir, file)
 			size := fileSizeMust(path)
 			line := fmt.Sprintf("%s: %d", path, size)
 			lines = append(lines, line)
+			sha256Lines = append(sha256Lines, fmt.Sprintf("%s  %s", sha256HexOfFileMust(path), path))
+
+			if strings.HasSuffix(file, ".exe") || strings.HasSuffix(file, ".dll") {
+				if sizes[platform] == nil {
+					sizes[platform] = map[string]*PESectionSizes{}
+				}
+				sizes[platform][file] = parsePESectionSizesMust(path)
+			}
 		}
 	}
 
@@ -354,6 +725,172 @@ func createManifestMust() {
 	createDirMust(artifactsDir)
 	path := filepath.Join(artifactsDir, "manifest.txt")
 	writeFileMust(path, []byte(s))
+
+	sha256Path := filepath.Join(artifactsDir, "manifest.sha256")
+	writeFileMust(sha256Path, []byte(strings.Join(sha256Lines, "\n")))
+
+	d, err := json.MarshalIndent(sizes, "", "  ")
+	must(err)
+	writeFileMust(filepath.Join(artifactsDir, "sizes.json"), d)
+}
+
+// platformForDir maps a platform output dir back to its kPlatform* constant.
+func platformForDir(dir string) string {
+	switch dir {
+	case rel32Dir:
+		return kPlatformIntel32
+	case rel64Dir:
+		return kPlatformIntel64
+	case relArm64Dir:
+		return kPlatformArm64
+	}
+	panicIf(true, "platformForDir: unrecognized dir '%s'", dir)
+	return ""
+}
+
+// PESectionSizes is the per-binary breakdown written to sizes.json, parsed
+// out of a shipped .exe / .dll's PE section headers.
+type PESectionSizes struct {
+	Size     int64
+	TextSize int64
+	DataSize int64
+	RsrcSize int64
+}
+
+// parsePESectionSizesMust parses path's PE section headers into a
+// PESectionSizes, so createManifestMust can track binary size over time.
+func parsePESectionSizesMust(path string) *PESectionSizes {
+	fi, err := os.Stat(path)
+	must(err)
+	s := &PESectionSizes{Size: fi.Size()}
+
+	f, err := pe.Open(path)
+	must(err)
+	defer f.Close()
+	for _, sec := range f.Sections {
+		name := strings.ToLower(strings.TrimRight(sec.Name, "\x00"))
+		switch name {
+		case ".text":
+			s.TextSize += int64(sec.Size)
+		case ".data", ".rdata":
+			s.DataSize += int64(sec.Size)
+		case ".rsrc":
+			s.RsrcSize += int64(sec.Size)
+		}
+	}
+	return s
+}
+
+// flgSizeRegressionPct / flgSizeRegressionAbs make the size regression gate
+// configurable: buildSizeReport fails on the larger of the two thresholds.
+// The defaults match what shipped before these were flags.
+var (
+	flgSizeRegressionPct = flag.Float64("size-regression-pct", 2.0, "fail buildCiDaily if a shipped binary grows by more than this percent vs. the last successful build")
+	flgSizeRegressionAbs = flag.Int64("size-regression-abs", 64*1024, "fail buildCiDaily if a shipped binary grows by more than this many bytes vs. the last successful build")
+)
+
+// lastSizesVerKey points at the version of the most recently *successful*
+// daily build that published a sizes.json, so buildSizeReport has a real
+// predecessor to diff against. buildCiDaily runs once a day while commits
+// land continuously, so "ver - 1" (the previous linear git version) almost
+// never corresponds to a build that actually ran; this pointer is updated
+// only after a build's sizes.json has been uploaded, so it always names a
+// real prior build.
+const lastSizesVerKey = "software/sumatrapdf/prerel/last-sizes-ver.txt"
+
+// buildSizeReport downloads the sizes.json published by the last successful
+// daily pre-release build (per lastSizesVerKey, not ver-1) and diffs it
+// against the one writeCiDailySizesJSONMust just wrote to out/artifacts/sizes.json,
+// panicking if any shipped binary grew by more than the larger of
+// flgSizeRegressionPct or flgSizeRegressionAbs.
+func buildSizeReport() {
+	mc := newMinioR2Client()
+	if !mc.Exists(lastSizesVerKey) {
+		logf("buildSizeReport: no previous build recorded at '%s', skipping\n", lastSizesVerKey)
+		return
+	}
+	prevVerPath := filepath.Join("out", "artifacts", "last-sizes-ver.txt")
+	mc.DownloadFile(prevVerPath, lastSizesVerKey)
+	prevVerData, err := os.ReadFile(prevVerPath)
+	must(err)
+	os.Remove(prevVerPath)
+	prevVer := strings.TrimSpace(string(prevVerData))
+
+	prevKey := "software/sumatrapdf/prerel/" + prevVer + "-unsigned/sizes.json"
+	if !mc.Exists(prevKey) {
+		logf("buildSizeReport: no sizes.json for previous version '%s', skipping\n", prevVer)
+		return
+	}
+
+	prevPath := filepath.Join("out", "artifacts", "sizes.prev.json")
+	mc.DownloadFile(prevPath, prevKey)
+	defer os.Remove(prevPath)
+
+	prevData, err := os.ReadFile(prevPath)
+	must(err)
+	var prev map[string]map[string]*PESectionSizes
+	must(json.Unmarshal(prevData, &prev))
+
+	currData, err := os.ReadFile(filepath.Join("out", "artifacts", "sizes.json"))
+	must(err)
+	var curr map[string]map[string]*PESectionSizes
+	must(json.Unmarshal(currData, &curr))
+
+	var failed bool
+	for platform, files := range curr {
+		for file, size := range files {
+			prevSize, ok := prev[platform][file]
+			if !ok {
+				continue
+			}
+			delta := size.Size - prevSize.Size
+			pct := 0.0
+			if prevSize.Size > 0 {
+				pct = float64(delta) / float64(prevSize.Size) * 100
+			}
+			logf("size: %s / %s: %d -> %d (%+.1f%%)\n", platform, file, prevSize.Size, size.Size, pct)
+
+			threshold := int64(float64(prevSize.Size) * *flgSizeRegressionPct / 100)
+			if threshold < *flgSizeRegressionAbs {
+				threshold = *flgSizeRegressionAbs
+			}
+			if delta > threshold {
+				logf("SIZE REGRESSION: %s / %s grew by %d bytes (threshold %d)\n", platform, file, delta, threshold)
+				failed = true
+			}
+		}
+	}
+	panicIf(failed, "buildSizeReport: one or more binaries exceeded the size regression threshold")
+}
+
+// sha256HexOfFileMust returns the sha256sum-compatible hex digest of path.
+func sha256HexOfFileMust(path string) string {
+	d, err := os.ReadFile(path)
+	must(err)
+	h := sha256.Sum256(d)
+	return hex.EncodeToString(h[:])
+}
+
+// verifyManifestMust re-reads dir's manifest.sha256 and re-hashes every
+// artifact it lists, panicking on the first mismatch. Called right before
+// upload so buildCiDaily / buildRelease fail fast if a file was modified
+// between build and upload.
+func verifyManifestMust(dir string) {
+	path := filepath.Join(dir, "manifest.sha256")
+	d, err := os.ReadFile(path)
+	must(err)
+	lines := strings.Split(strings.TrimSpace(string(d)), "\n")
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		parts := strings.SplitN(line, "  ", 2)
+		panicIf(len(parts) != 2, "invalid line in '%s': '%s'", path, line)
+		wantHash, artifactPath := parts[0], parts[1]
+		gotHash := sha256HexOfFileMust(artifactPath)
+		panicIf(gotHash != wantHash, "checksum mismatch for '%s': expected %s, got %s", artifactPath, wantHash, gotHash)
+	}
+	logf("verifyManifestMust: verified %d files against '%s'\n", len(lines), path)
 }
 
 // func listFilesInDir(dir string) {
@@ -383,6 +920,168 @@ func signFilesMust(dir string) {
 	signMust(filepath.Join(dir, "SumatraPDF-dll.exe"))
 }
 
+// signifyPrivateKey is a decoded, decrypted minisign secret key: the key id
+// identifying which public key verifies it, and the raw ed25519 key
+// material, as produced by `minisign -G`.
+type signifyPrivateKey struct {
+	keyID [8]byte
+	sk    ed25519.PrivateKey
+}
+
+// scryptPickParams reproduces libsodium's
+// crypto_pwhash_scryptsalsa208sha256 parameter derivation, turning the
+// opslimit/memlimit stored in a minisign secret key into the (N, r, p)
+// scrypt expects. minisign stores opslimit/memlimit rather than N/r/p
+// directly so that keys stay portable across machines with different
+// memory budgets.
+func scryptPickParams(opslimit, memlimit uint64) (n int, r int, p int) {
+	if opslimit < 32768 {
+		opslimit = 32768
+	}
+	r = 8
+	log2N := uint(1)
+	if opslimit < memlimit/32 {
+		p = 1
+		maxN := opslimit / (uint64(r) * 4)
+		for ; log2N < 63; log2N++ {
+			if uint64(1)<<log2N > maxN/2 {
+				break
+			}
+		}
+	} else {
+		maxN := memlimit / (uint64(r) * 128)
+		for ; log2N < 63; log2N++ {
+			if uint64(1)<<log2N > maxN/2 {
+				break
+			}
+		}
+		maxrp := (opslimit / 4) / (uint64(1) << log2N)
+		if maxrp > 0x3fffffff {
+			maxrp = 0x3fffffff
+		}
+		p = int(uint32(maxrp) / uint32(r))
+	}
+	return 1 << log2N, r, p
+}
+
+// loadSignifyKeyMust reads a minisign secret key from the named env var
+// (the base64 blob produced by `minisign -G`), decrypting it with
+// keyEnvVar+"_PASSPHRASE" if it's scrypt-protected, the same way ci.go's
+// `-signify key-envvar` flag does.
+func loadSignifyKeyMust(keyEnvVar string) *signifyPrivateKey {
+	blob := os.Getenv(keyEnvVar)
+	panicIf(blob == "", "env variable '%s' is not set", keyEnvVar)
+	lines := strings.Split(strings.TrimSpace(blob), "\n")
+	panicIf(len(lines) < 2, "'%s' doesn't look like a minisign secret key", keyEnvVar)
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	must(err)
+	// minisign secret key layout: 2 byte sig_alg, 2 byte kdf_alg,
+	// 2 byte chk_alg, 32 byte salt, 8 byte opslimit (LE), 8 byte memlimit
+	// (LE), 8 byte keynum, 64 byte (encrypted) ed25519 secret key, 32 byte
+	// checksum. keynum/seed/checksum are encrypted together as one block.
+	const keynumSkLen = 8 + 64 + 32
+	panicIf(len(raw) != 2+2+2+32+8+8+keynumSkLen, "unexpected minisign secret key length %d", len(raw))
+	kdfAlg := string(raw[2:4])
+	salt := raw[6:38]
+	opslimit := binary.LittleEndian.Uint64(raw[38:46])
+	memlimit := binary.LittleEndian.Uint64(raw[46:54])
+	keynumSk := make([]byte, keynumSkLen)
+	copy(keynumSk, raw[54:54+keynumSkLen])
+
+	if kdfAlg != "\x00\x00" {
+		passphrase := os.Getenv(keyEnvVar + "_PASSPHRASE")
+		panicIf(passphrase == "", "key '%s' is passphrase-protected but '%s_PASSPHRASE' is not set", keyEnvVar, keyEnvVar)
+		n, r, p := scryptPickParams(opslimit, memlimit)
+		xorKey, err := scrypt.Key([]byte(passphrase), salt, n, r, p, keynumSkLen)
+		must(err)
+		for i := range keynumSk {
+			keynumSk[i] ^= xorKey[i]
+		}
+	}
+
+	var keyID [8]byte
+	copy(keyID[:], keynumSk[:8])
+	sk := keynumSk[8 : 8+64]
+
+	return &signifyPrivateKey{keyID: keyID, sk: ed25519.PrivateKey(sk)}
+}
+
+// signifySignFileMust writes path+".sig" in the signify/minisign wire
+// format: an untrusted-comment line, the base64 of "Ed" + 8-byte key id +
+// 64-byte ed25519 signature, a trusted-comment line, and the base64 of a
+// second ed25519 signature over (signature bytes || trusted comment).
+func signifySignFileMust(key *signifyPrivateKey, path string) {
+	data, err := os.ReadFile(path)
+	must(err)
+	sig := ed25519.Sign(key.sk, data)
+
+	sigBlob := make([]byte, 0, 2+8+len(sig))
+	sigBlob = append(sigBlob, 'E', 'd')
+	sigBlob = append(sigBlob, key.keyID[:]...)
+	sigBlob = append(sigBlob, sig...)
+
+	trustedComment := fmt.Sprintf("timestamp:%d\tfile:%s", time.Now().Unix(), filepath.Base(path))
+	globalMsg := append(append([]byte{}, sig...), []byte(trustedComment)...)
+	globalSig := ed25519.Sign(key.sk, globalMsg)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "untrusted comment: signature from signify secret key\n")
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(sigBlob))
+	fmt.Fprintf(&out, "trusted comment: %s\n", trustedComment)
+	fmt.Fprintf(&out, "%s\n", base64.StdEncoding.EncodeToString(globalSig))
+
+	writeFileMust(path+".sig", out.Bytes())
+}
+
+// signifyFilesMust signs every file in dir (skipping any existing .sig) with
+// the secret key named by keyEnvVar, called from buildRelease and
+// buildPreRelease for every file written into finalPreRelDir. This gives
+// downloaders a detached ed25519 signature alongside Authenticode, so they
+// don't have to trust the CA chain.
+func signifyFilesMust(dir string, keyEnvVar string) {
+	if os.Getenv(keyEnvVar) == "" {
+		logf("signifyFilesMust: '%s' not set, skipping signing '%s'\n", keyEnvVar, dir)
+		return
+	}
+	key := loadSignifyKeyMust(keyEnvVar)
+	entries, err := os.ReadDir(dir)
+	must(err)
+	nSigned := 0
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), ".sig") {
+			continue
+		}
+		signifySignFileMust(key, filepath.Join(dir, e.Name()))
+		nSigned++
+	}
+	logf("signifyFilesMust: signed %d files in '%s'\n", nSigned, dir)
+}
+
+// appendSignifyFingerprintMust appends the signify public key's fingerprint
+// (its key id, hex-encoded) to manifest.txt so downloaders can line it up
+// against the public key they trust without re-deriving it.
+func appendSignifyFingerprintMust(keyEnvVar string) {
+	pubBlob := os.Getenv(keyEnvVar + "_PUB")
+	if pubBlob == "" {
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(pubBlob), "\n")
+	panicIf(len(lines) < 2, "'%s_PUB' doesn't look like a minisign public key", keyEnvVar)
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	must(err)
+	// minisign public key layout: 2 byte sig_alg, 8 byte keynum, 32 byte
+	// pubkey. The key id downloaders compute from the public key is keynum,
+	// i.e. raw[2:10].
+	panicIf(len(raw) != 2+8+32, "unexpected minisign public key length %d", len(raw))
+	fingerprint := hex.EncodeToString(raw[2:10])
+
+	path := filepath.Join("out", "artifacts", "manifest.txt")
+	d, err := os.ReadFile(path)
+	must(err)
+	s := string(d) + fmt.Sprintf("\nsignify-fingerprint: %s", fingerprint)
+	writeFileMust(path, []byte(s))
+}
+
 const (
 	kPlatformIntel32 = "Win32"
 	kPlatformIntel64 = "x64"
@@ -426,6 +1125,7 @@ func build(config, platform string) {
 	runExeLoggedMust(msbuildPath, slnPath, `/t:SumatraPDF:Rebuild;SumatraPDF-dll:Rebuild;PdfFilter:Rebuild;PdfPreview:Rebuild`, p, `/m`)
 	createPdbZipMust(dir)
 	createPdbLzsaMust(dir)
+	createPdbCompressedMust(dir, getCompressorMust(buildCompressKind))
 }
 
 // builds more targets, even those not used, to prevent code rot
@@ -507,6 +1207,7 @@ func ensureManualIsBuilt() {
 func buildPreRelease(platform string, all bool) {
 	// make sure we can sign the executables, early exit if missing
 	detectSigntoolPath()
+	applyCompressFlagMust()
 
 	ensureManualIsBuilt()
 
@@ -529,16 +1230,21 @@ func buildPreRelease(platform string, all bool) {
 	createExeZipWithGoWithNameMust(outDir, nameInZip)
 
 	createManifestMust()
+	appendSignifyFingerprintMust(signifyKeyEnvVar)
+	verifyManifestMust(filepath.Join("out", "artifacts"))
 
 	dstDir := getFinalDirForBuildType(buildTypePreRel)
 	prefix := "SumatraPDF-prerel"
 	copyBuiltFiles(dstDir, outDir, prefix+"-"+suffix)
 	copyBuiltManifest(dstDir, prefix)
+	signifyFilesMust(dstDir, signifyKeyEnvVar)
 }
 
 func buildRelease() {
 	// make sure we can sign the executables, early exit if missing
 	detectSigntoolPath()
+	applyCompressFlagMust()
+	applyZopfliFlag()
 	genHTMLDocsForApp()
 
 	ver := getVerForBuildType(buildTypeRel)
@@ -553,18 +1259,25 @@ func buildRelease() {
 	defer revertBuildConfig()
 
 	build("Release", kPlatformIntel32)
-	nameInZip := fmt.Sprintf("SumatraPDF-%s-32.exe", ver)
-	createExeZipWithGoWithNameMust(rel32Dir, nameInZip)
-
 	build("Release", kPlatformIntel64)
-	nameInZip = fmt.Sprintf("SumatraPDF-%s-64.exe", ver)
-	createExeZipWithGoWithNameMust(rel64Dir, nameInZip)
-
 	build("Release", kPlatformArm64)
-	nameInZip = fmt.Sprintf("SumatraPDF-%s-arm64.exe", ver)
-	createExeZipWithGoWithNameMust(relArm64Dir, nameInZip)
+
+	if useZopfli {
+		createExeZipsWithZopfliParallelMust([]string{rel32Dir, rel64Dir, relArm64Dir}, ver)
+	} else {
+		nameInZip := fmt.Sprintf("SumatraPDF-%s-32.exe", ver)
+		createExeZipWithGoWithNameMust(rel32Dir, nameInZip)
+
+		nameInZip = fmt.Sprintf("SumatraPDF-%s-64.exe", ver)
+		createExeZipWithGoWithNameMust(rel64Dir, nameInZip)
+
+		nameInZip = fmt.Sprintf("SumatraPDF-%s-arm64.exe", ver)
+		createExeZipWithGoWithNameMust(relArm64Dir, nameInZip)
+	}
 
 	createManifestMust()
+	appendSignifyFingerprintMust(signifyKeyEnvVar)
+	verifyManifestMust(filepath.Join("out", "artifacts"))
 
 	dstDir := getFinalDirForBuildType(buildTypeRel)
 	prefix := fmt.Sprintf("SumatraPDF-%s", ver)
@@ -572,6 +1285,202 @@ func buildRelease() {
 	copyBuiltFiles(dstDir, rel64Dir, prefix+"-64")
 	copyBuiltFiles(dstDir, relArm64Dir, prefix+"-arm64")
 	copyBuiltManifest(dstDir, prefix)
+	signifyFilesMust(dstDir, signifyKeyEnvVar)
+}
+
+// VerifyFileReport is the per-file result of comparing a locally rebuilt
+// artifact against the one already published for the same gitSha1.
+type VerifyFileReport struct {
+	Name           string
+	Platform       string
+	ExpectedSHA256 string
+	GotSHA256      string
+	Match          bool
+	Diff           string
+}
+
+// VerifyReport is written to out/artifacts/verify-report.json by buildVerify.
+type VerifyReport struct {
+	Start           time.Time
+	End             time.Time
+	GitSha1         string
+	BuildConfigHash string
+	Files           []*VerifyFileReport
+}
+
+// imageDirectoryEntrySecurity is the index of the Authenticode certificate
+// table in a PE optional header's DataDirectory array.
+const imageDirectoryEntrySecurity = 4
+
+// stripAuthenticodeSignatureMust returns a copy of data without the
+// IMAGE_DIRECTORY_ENTRY_SECURITY block (the Authenticode signature), with
+// that directory entry and the optional header's CheckSum zeroed, so a
+// locally built, unsigned binary can be hashed and compared against a signed,
+// published one. Unlike other data directory entries, VirtualAddress for this
+// one is a raw file offset rather than an RVA.
+func stripAuthenticodeSignatureMust(data []byte) []byte {
+	f, err := pe.NewFile(bytes.NewReader(data))
+	must(err)
+	defer f.Close()
+
+	var off, size uint32
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dd := oh.DataDirectory[imageDirectoryEntrySecurity]
+		off, size = dd.VirtualAddress, dd.Size
+	case *pe.OptionalHeader64:
+		dd := oh.DataDirectory[imageDirectoryEntrySecurity]
+		off, size = dd.VirtualAddress, dd.Size
+	default:
+		panic("stripAuthenticodeSignatureMust: unrecognized optional header type")
+	}
+	if size == 0 {
+		// not signed, nothing to strip
+		off = uint32(len(data))
+	}
+	panicIf(int64(off)+int64(size) > int64(len(data)), "certificate directory out of bounds")
+	// Authenticode signing appends the certificate table after the last
+	// section, growing the file; an unsigned rebuild has no such trailing
+	// bytes. Truncate to off (rather than zero-filling to the original
+	// length) so a signed and an unsigned build of the same source hash
+	// identically.
+	out := make([]byte, off)
+	copy(out, data[:off])
+	// Signing also fills in the security directory entry and recomputes the
+	// CheckSum (the Authenticode hash itself excludes both), so zero them.
+	optOff := int(binary.LittleEndian.Uint32(out[0x3c:])) + 4 + 20
+	ddOff := optOff + 96
+	if _, ok := f.OptionalHeader.(*pe.OptionalHeader64); ok {
+		ddOff = optOff + 112
+	}
+	binary.LittleEndian.PutUint32(out[optOff+64:], 0)
+	binary.LittleEndian.PutUint64(out[ddOff+8*imageDirectoryEntrySecurity:], 0)
+	return out
+}
+
+// hashArtifactForVerifyMust strips the Authenticode signature (if any) from
+// path and returns the SHA-256 of the normalized bytes.
+func hashArtifactForVerifyMust(path string) string {
+	d, err := os.ReadFile(path)
+	must(err)
+	d = stripAuthenticodeSignatureMust(d)
+	h := sha256.Sum256(d)
+	return hex.EncodeToString(h[:])
+}
+
+// r2ArtifactDownloader is the subset of the R2 client buildVerify needs to
+// fetch a published artifact; declared locally so
+// downloadPublishedArtifactMust doesn't have to name the concrete client
+// type returned by newMinioR2Client().
+type r2ArtifactDownloader interface {
+	Exists(key string) bool
+	DownloadFile(dstPath, key string)
+}
+
+// downloadPublishedArtifactMust downloads key (what buildCiDaily would have
+// uploaded with --compress=zip, the default) into dstPath, falling back to
+// the .zst / .xz variants and decompressing them if the daily build instead
+// ran with --compress=zstd or --compress=xz. Without this, buildVerify only
+// ever found the plain key and failed every comparison whenever the daily
+// build used a non-default --compress.
+func downloadPublishedArtifactMust(mc r2ArtifactDownloader, key, dstPath string) {
+	if mc.Exists(key) {
+		mc.DownloadFile(dstPath, key)
+		return
+	}
+	for _, c := range []Compressor{zstdCompressorT{}, xzCompressorT{}} {
+		compressedKey := key + "." + extForCompressor(c)
+		if !mc.Exists(compressedKey) {
+			continue
+		}
+		compressedPath := dstPath + "." + extForCompressor(c)
+		mc.DownloadFile(compressedPath, compressedKey)
+		defer os.Remove(compressedPath)
+		detectCompressorFromMagicMust(compressedPath).DecompressFileMust(dstPath, compressedPath)
+		return
+	}
+	panicIf(mc.Exists(key+".lzsa"), "downloadPublishedArtifactMust: '%s' was published as .lzsa, which can't be decompressed for verification (MakeLZSA has no extraction mode)", key)
+	panic(fmt.Sprintf("downloadPublishedArtifactMust: no object found for '%s' (tried uncompressed, .zst, .xz)", key))
+}
+
+// buildVerify rebuilds SumatraPDF from the current tree and compares the
+// resulting SumatraPDF.exe / SumatraPDF-dll.exe / libmupdf.dll / PdfFilter.dll
+// / PdfPreview.dll against the artifacts already published for the same
+// gitSha1 / preReleaseVer, so anyone can independently confirm a published
+// installer was built from the source tree it claims to come from.
+func buildVerify() {
+	detectVersions()
+	ver := getPreReleaseVer()
+	s := fmt.Sprintf("verifying reproducibility of pre-release version %s", ver)
+	defer makePrintDuration(s)()
+
+	removeReleaseBuilds()
+	setBuildConfigPreRelease()
+	defer revertBuildConfig()
+
+	build("Release", kPlatformIntel32)
+	build("Release", kPlatformIntel64)
+	build("Release", kPlatformArm64)
+
+	buildConfigData, err := os.ReadFile(buildConfigPath())
+	must(err)
+	buildConfigHash := sha256.Sum256(buildConfigData)
+
+	report := &VerifyReport{
+		Start:           time.Now(),
+		GitSha1:         getGitSha1(),
+		BuildConfigHash: hex.EncodeToString(buildConfigHash[:]),
+	}
+
+	mc := newMinioR2Client()
+	filesToVerify := []string{"SumatraPDF.exe", "SumatraPDF-dll.exe", "libmupdf.dll", "PdfFilter.dll", "PdfPreview.dll"}
+	for _, platform := range []string{kPlatformIntel32, kPlatformIntel64, kPlatformArm64} {
+		suffix := getSuffixForPlatform(platform)
+		outDir := getOutDirForPlatform(platform)
+		keyPrefix := "software/sumatrapdf/prerel/" + ver + "-unsigned/" + platform + "/"
+		for _, name := range filesToVerify {
+			localPath := filepath.Join(outDir, name)
+			if !fileExists(localPath) {
+				// e.g. PdfFilter.dll / PdfPreview.dll aren't built for every platform
+				continue
+			}
+			got := hashArtifactForVerifyMust(localPath)
+
+			publishedPath := localPath + ".published"
+			downloadPublishedArtifactMust(mc, keyPrefix+name, publishedPath)
+			want := hashArtifactForVerifyMust(publishedPath)
+			os.Remove(publishedPath)
+
+			fr := &VerifyFileReport{
+				Name:           name,
+				Platform:       suffix,
+				ExpectedSHA256: want,
+				GotSHA256:      got,
+				Match:          want == got,
+			}
+			if !fr.Match {
+				fr.Diff = fmt.Sprintf("sha256 mismatch for %s / %s: expected %s, got %s", platform, name, want, got)
+			}
+			report.Files = append(report.Files, fr)
+		}
+	}
+	report.End = time.Now()
+
+	d, err := json.MarshalIndent(report, "", "  ")
+	must(err)
+	artifactsDir := filepath.Join("out", "artifacts")
+	createDirMust(artifactsDir)
+	writeFileMust(filepath.Join(artifactsDir, "verify-report.json"), d)
+
+	nMismatch := 0
+	for _, fr := range report.Files {
+		if !fr.Match {
+			nMismatch++
+			logf("MISMATCH: %s\n", fr.Diff)
+		}
+	}
+	panicIf(nMismatch > 0, "buildVerify: %d of %d file(s) didn't match the published artifacts", nMismatch, len(report.Files))
+	logf("buildVerify: all %d files matched the published artifacts\n", len(report.Files))
 }
 
 func detectVersionsCodeQL() {
@@ -637,12 +1546,12 @@ func buildTestUtil() {
 
 // build pre-release builds and upload unsigned binaries to r2
 // TODO: remove old unsigned builds, keep only the last one; do it after we check thie build doesn't exist
-// TODO: maybe compress files before uploading using zstd or brotli
 func buildCiDaily() {
 	if !isGithubMyMasterBranch() {
 		logf("buildCiDaily: skipping build because not on master branch\n")
 		return
 	}
+	applyCompressFlagMust()
 
 	msbuildPath := detectMsbuildPath()
 
@@ -674,27 +1583,48 @@ func buildCiDaily() {
 		printBBuildDur := makePrintDuration(fmt.Sprintf("buidling pre-release %s version %s", platform, ver))
 		slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
 		p := `/p:Configuration=Release;Platform=` + platform
-		runExeLoggedMust(msbuildPath, slnPath, `/t:SumatraPDF:Rebuild;SumatraPDF-dll:Rebuild`, p, `/m`)
+		runExeLoggedMust(msbuildPath, slnPath, `/t:SumatraPDF:Rebuild;SumatraPDF-dll:Rebuild;PdfFilter:Rebuild;PdfPreview:Rebuild`, p, `/m`)
 		printBBuildDur()
 
+		dir := getOutDirForPlatform(platform)
+		// buildVerify compares these same five files against this exact
+		// R2 prefix, so keep this list in sync with its filesToVerify.
+		files := []string{
+			"SumatraPDF.exe",
+			"SumatraPDF-dll.exe",
+			"libmupdf.dll",
+			"PdfFilter.dll",
+			"PdfPreview.dll",
+			"SumatraPDF.pdb",
+			"SumatraPDF-dll.pdb",
+		}
+		writeCiDailyChecksumsMust(dir, files)
+
 		wgUploads.Add(1)
-		go func(platform string) {
+		go func(platform string, dir string, files []string) {
 			defer wgUploads.Done()
-			dir := getOutDirForPlatform(platform)
-			files := []string{
-				"SumatraPDF.exe",
-				"SumatraPDF-dll.exe",
-				"SumatraPDF.pdb",
-				"SumatraPDF-dll.pdb",
-			}
+			// fail fast if a file was modified between build and upload
+			verifyManifestMust(dir)
+
+			// --compress=zstd|xz trades upload size for extra CPU; zip (the
+			// default) uploads the files as-is since they're still unsigned
+			// and will be re-packaged once signed.
+			c := getCompressorMust(buildCompressKind)
 			for _, file := range files {
 				path := filepath.Join(dir, file)
-				key := keyPrefix + platform + "/" + file
+				uploadName := file
+				if c.Name() != "zip" {
+					uploadName = file + "." + extForCompressor(c)
+					uploadPath := filepath.Join(dir, uploadName)
+					c.CompressFileMust(uploadPath, path)
+					path = uploadPath
+				}
+				key := keyPrefix + platform + "/" + uploadName
 				printDur := makePrintDuration(fmt.Sprintf("uploading '%s' to '%s'\n", path, key))
 				mc.UploadFile(key, path, true)
 				printDur()
 			}
-		}(platform)
+		}(platform, dir, files)
 	}
 	revertBuildConfig() // can do twice
 	printAllBuildDur()
@@ -702,6 +1632,50 @@ func buildCiDaily() {
 	logf("uploading '%s'\n", keyAllBuild)
 	mc.UploadData(keyAllBuild, []byte("all builds"), true)
 	wgUploads.Wait()
+
+	writeCiDailySizesJSONMust(ver)
+	buildSizeReport()
+	mc.UploadFile(keyPrefix+"sizes.json", filepath.Join("out", "artifacts", "sizes.json"), true)
+	// record this build as the new "last successful" one so tomorrow's
+	// buildSizeReport has a real predecessor to diff against, not ver-1.
+	mc.UploadData(lastSizesVerKey, []byte(ver), true)
+}
+
+// writeCiDailyChecksumsMust writes dir/manifest.sha256 for files right after
+// they're built, so verifyManifestMust can catch a file getting modified
+// between build and upload the same way buildRelease / buildPreRelease do.
+func writeCiDailyChecksumsMust(dir string, files []string) {
+	var lines []string
+	for _, file := range files {
+		path := filepath.Join(dir, file)
+		lines = append(lines, fmt.Sprintf("%s  %s", sha256HexOfFileMust(path), path))
+	}
+	writeFileMust(filepath.Join(dir, "manifest.sha256"), []byte(strings.Join(lines, "\n")))
+}
+
+// writeCiDailySizesJSONMust writes out/artifacts/sizes.json for the
+// SumatraPDF.exe / SumatraPDF-dll.exe built by buildCiDaily, so
+// buildSizeReport has something to diff against tomorrow's build.
+func writeCiDailySizesJSONMust(ver string) {
+	sizes := map[string]map[string]*PESectionSizes{}
+	for _, platform := range []string{kPlatformIntel32, kPlatformIntel64, kPlatformArm64} {
+		dir := getOutDirForPlatform(platform)
+		for _, file := range []string{"SumatraPDF.exe", "SumatraPDF-dll.exe"} {
+			path := filepath.Join(dir, file)
+			if !fileExists(path) {
+				continue
+			}
+			if sizes[platform] == nil {
+				sizes[platform] = map[string]*PESectionSizes{}
+			}
+			sizes[platform][file] = parsePESectionSizesMust(path)
+		}
+	}
+	d, err := json.MarshalIndent(sizes, "", "  ")
+	must(err)
+	artifactsDir := filepath.Join("out", "artifacts")
+	createDirMust(artifactsDir)
+	writeFileMust(filepath.Join(artifactsDir, "sizes.json"), d)
 }
 
 func waitForEnter(s string) {